@@ -0,0 +1,91 @@
+package influxdb
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+// Buffer is a ring buffer of points pending a retried write. It has hard
+// caps on both the number of points and the total line-protocol bytes it
+// will hold, so neither a prolonged InfluxDB outage nor a handful of huge
+// points can grow the buffer without bound; once either cap is hit, the
+// oldest points are evicted to make room for new ones and counted as
+// dropped.
+type Buffer struct {
+	mu       sync.Mutex
+	points   []*sink.Point
+	sizes    []int
+	bytes    int
+	maxSize  int
+	maxBytes int
+
+	dropped uint64
+}
+
+// NewBuffer creates a Buffer that holds at most maxSize points and maxBytes
+// bytes of line protocol, whichever limit is hit first. Either limit being
+// <= 0 disables that cap.
+func NewBuffer(maxSize, maxBytes int) *Buffer {
+	return &Buffer{maxSize: maxSize, maxBytes: maxBytes}
+}
+
+// Add appends points to the buffer, evicting the oldest ones first once the
+// buffer is at its point or byte capacity.
+func (b *Buffer) Add(points ...*sink.Point) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, p := range points {
+		size := len(sink.LineProtocol(p))
+		b.points = append(b.points, p)
+		b.sizes = append(b.sizes, size)
+		b.bytes += size
+	}
+
+	for b.overCapLocked() {
+		b.bytes -= b.sizes[0]
+		b.points = b.points[1:]
+		b.sizes = b.sizes[1:]
+		atomic.AddUint64(&b.dropped, 1)
+	}
+}
+
+func (b *Buffer) overCapLocked() bool {
+	if len(b.points) == 0 {
+		return false
+	}
+	if b.maxSize > 0 && len(b.points) > b.maxSize {
+		return true
+	}
+	if b.maxBytes > 0 && b.bytes > b.maxBytes {
+		return true
+	}
+	return false
+}
+
+// Elements returns and clears the currently buffered points.
+func (b *Buffer) Elements() []*sink.Point {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	points := b.points
+	b.points = nil
+	b.sizes = nil
+	b.bytes = 0
+	return points
+}
+
+// Len reports how many points are currently buffered.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.points)
+}
+
+// Dropped reports how many points have been evicted from the buffer because
+// it was at capacity.
+func (b *Buffer) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
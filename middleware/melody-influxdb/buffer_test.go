@@ -0,0 +1,36 @@
+package influxdb
+
+import (
+	"testing"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+func TestBufferEvictsOnByteCap(t *testing.T) {
+	big := &sink.Point{Measurement: "cpu", Fields: map[string]interface{}{"v": "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"}}
+	small := &sink.Point{Measurement: "cpu", Fields: map[string]interface{}{"v": 1}}
+
+	maxBytes := len(sink.LineProtocol(big)) + len(sink.LineProtocol(small)) - 1
+
+	b := NewBuffer(0, maxBytes)
+	b.Add(big, small)
+
+	if got := b.Len(); got != 1 {
+		t.Fatalf("Len() = %d, want 1 after exceeding the byte cap", got)
+	}
+	if got := b.Dropped(); got != 1 {
+		t.Fatalf("Dropped() = %d, want 1", got)
+	}
+}
+
+func TestBufferEvictsOnPointCap(t *testing.T) {
+	b := NewBuffer(2, 0)
+	b.Add(points(3)...)
+
+	if got := b.Len(); got != 2 {
+		t.Fatalf("Len() = %d, want 2 after exceeding the point cap", got)
+	}
+	if got := b.Dropped(); got != 1 {
+		t.Fatalf("Dropped() = %d, want 1", got)
+	}
+}
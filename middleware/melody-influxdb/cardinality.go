@@ -0,0 +1,67 @@
+package influxdb
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const cardinalityOverflowValue = "__cardinality_overflow__"
+
+// cardinalityGuard bounds how many distinct tag-value combinations a
+// measurement may contribute before its points start getting collapsed
+// into a single overflow series. Without it, a label like a raw URL path
+// (with IDs still in it) can make InfluxDB's series count grow without
+// bound.
+type cardinalityGuard struct {
+	mu      sync.Mutex
+	ceiling int
+	seen    map[string]*boundedLRU // measurement -> LRU of series keys admitted so far
+	dropped uint64
+}
+
+func newCardinalityGuard(ceiling int) *cardinalityGuard {
+	return &cardinalityGuard{
+		ceiling: ceiling,
+		seen:    make(map[string]*boundedLRU),
+	}
+}
+
+// Admit reports whether seriesKey (a point's tag set) is within the
+// measurement's cardinality ceiling. Once a measurement has reached its
+// ceiling, any series it hasn't already seen is rejected so the point can
+// be collapsed into an overflow series instead of writing a brand-new one.
+func (g *cardinalityGuard) Admit(measurement, seriesKey string) bool {
+	if g.ceiling <= 0 {
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	series, ok := g.seen[measurement]
+	if !ok {
+		series = newBoundedLRU(g.ceiling)
+		g.seen[measurement] = series
+	}
+
+	if _, ok := series.Get(seriesKey); ok {
+		return true
+	}
+
+	if series.Len() >= g.ceiling {
+		atomic.AddUint64(&g.dropped, 1)
+		return false
+	}
+
+	series.Set(seriesKey, struct{}{})
+	return true
+}
+
+// Dropped reports how many points have been collapsed into an overflow
+// bucket because their series was new while their measurement was at its
+// cardinality ceiling. A rejected series is counted again for every point
+// it sends, not once. It is surfaced as the melody_dropped_series internal
+// counter.
+func (g *cardinalityGuard) Dropped() uint64 {
+	return atomic.LoadUint64(&g.dropped)
+}
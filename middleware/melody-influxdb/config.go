@@ -0,0 +1,275 @@
+package influxdb
+
+import (
+	"errors"
+	"regexp"
+	"time"
+
+	"melody/config"
+)
+
+const (
+	Namespace = "github.com/melody/melody-influxdb"
+
+	dataServerDefaultPort          = ":8121"
+	dataServerDefaultWebSocketPort = ":8122"
+
+	driverV1   = "v1"
+	driverV2   = "v2"
+	driverFile = "file"
+)
+
+var configErr = errors.New("unable to extract the influxdb configuration")
+
+// influxdbConfig is the result of parsing the "github.com/melody/melody-influxdb"
+// key out of the service's extra_config.
+type influxdbConfig struct {
+	driver string
+
+	// common
+	address string
+	timeout time.Duration
+	ttl     time.Duration
+	db      string
+
+	// bufferSize caps how many points the ring buffer holds for points that
+	// failed all of their retries; once full, the oldest points are dropped.
+	bufferSize int
+
+	// bufferMaxBytes caps the ring buffer's total line-protocol size, so a
+	// handful of unusually large points can't exhaust memory before
+	// bufferSize is reached. 0 disables the byte cap.
+	bufferMaxBytes int
+
+	// async writer
+	batchSize            int
+	flushInterval        time.Duration
+	retryInterval        time.Duration
+	retryExponentialBase float64
+	maxRetries           int
+	maxRetryTime         time.Duration
+
+	// v1 (InfluxQL, database, basic auth)
+	username string
+	password string
+
+	// v2 (Flux, org/bucket, token, TLS)
+	token              string
+	org                string
+	bucket             string
+	insecureSkipVerify bool
+
+	// file (offline/debug)
+	filePath string
+
+	dataServerEnable      bool
+	dataServerPort        string
+	dataServerQueryEnable bool
+
+	// query proxy
+	jwtSecret    string
+	tenantTag    string
+	queryTimeout time.Duration
+	maxRows      int
+
+	// exporters lists the extra metrics backends to run alongside the
+	// InfluxDB sink above, e.g. []string{"prometheus", "kafka"}. Empty
+	// means InfluxDB only.
+	exporters []string
+
+	prometheusPath string
+
+	otlpEndpoint string
+	otlpProtocol string
+	otlpInsecure bool
+
+	kafkaBrokers []string
+	kafkaTopic   string
+
+	// tagRules normalize high-cardinality tag values (e.g. a raw URL path)
+	// before a point is admitted into the cardinality guard below.
+	tagRules []tagRule
+
+	// cardinalityCeiling caps how many distinct series a single measurement
+	// may contribute; series beyond it are collapsed into an overflow
+	// series instead of growing InfluxDB's series count unbounded. 0
+	// disables the guard.
+	cardinalityCeiling int
+
+	// downsampleInterval, when set, forwards at most one point per series
+	// per interval instead of one per collection tick.
+	downsampleInterval time.Duration
+}
+
+func getConfig(e config.ExtraConfig) interface{} {
+	v, ok := e[Namespace]
+	if !ok {
+		return nil
+	}
+
+	tmp, ok := v.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	cfg := influxdbConfig{
+		driver:               driverV1,
+		timeout:              5 * time.Second,
+		ttl:                  30 * time.Second,
+		bufferSize:           100000,
+		bufferMaxBytes:       64 * 1024 * 1024,
+		dataServerPort:       dataServerDefaultPort,
+		batchSize:            1000,
+		flushInterval:        time.Second,
+		retryInterval:        time.Second,
+		retryExponentialBase: 2,
+		maxRetries:           5,
+		maxRetryTime:         30 * time.Second,
+		queryTimeout:         10 * time.Second,
+		maxRows:              10000,
+		prometheusPath:       "/metrics",
+		otlpProtocol:         "grpc",
+	}
+
+	if d, ok := tmp["driver"].(string); ok && d != "" {
+		cfg.driver = d
+	}
+	if a, ok := tmp["address"].(string); ok {
+		cfg.address = a
+	}
+	if u, ok := tmp["username"].(string); ok {
+		cfg.username = u
+	}
+	if p, ok := tmp["password"].(string); ok {
+		cfg.password = p
+	}
+	if db, ok := tmp["db"].(string); ok {
+		cfg.db = db
+	}
+	if t, ok := tmp["token"].(string); ok {
+		cfg.token = t
+	}
+	if o, ok := tmp["org"].(string); ok {
+		cfg.org = o
+	}
+	if b, ok := tmp["bucket"].(string); ok {
+		cfg.bucket = b
+	}
+	if fp, ok := tmp["file_path"].(string); ok {
+		cfg.filePath = fp
+	}
+	if insecure, ok := tmp["insecure_skip_verify"].(bool); ok {
+		cfg.insecureSkipVerify = insecure
+	}
+	if en, ok := tmp["data_server_enable"].(bool); ok {
+		cfg.dataServerEnable = en
+	}
+	if qe, ok := tmp["data_server_query_enable"].(bool); ok {
+		cfg.dataServerQueryEnable = qe
+	}
+	if port, ok := tmp["data_server_port"].(string); ok && port != "" {
+		cfg.dataServerPort = port
+	}
+	if bs, ok := tmp["buffer_size"].(float64); ok && bs > 0 {
+		cfg.bufferSize = int(bs)
+	}
+	if bmb, ok := tmp["buffer_max_bytes"].(float64); ok && bmb > 0 {
+		cfg.bufferMaxBytes = int(bmb)
+	}
+	if bs, ok := tmp["batch_size"].(float64); ok && bs > 0 {
+		cfg.batchSize = int(bs)
+	}
+	if fi, ok := tmp["flush_interval"].(string); ok && fi != "" {
+		if d, err := time.ParseDuration(fi); err == nil {
+			cfg.flushInterval = d
+		}
+	}
+	if ri, ok := tmp["retry_interval"].(string); ok && ri != "" {
+		if d, err := time.ParseDuration(ri); err == nil {
+			cfg.retryInterval = d
+		}
+	}
+	if base, ok := tmp["retry_exponential_base"].(float64); ok && base > 0 {
+		cfg.retryExponentialBase = base
+	}
+	if mr, ok := tmp["max_retries"].(float64); ok && mr >= 0 {
+		cfg.maxRetries = int(mr)
+	}
+	if mrt, ok := tmp["max_retry_time"].(string); ok && mrt != "" {
+		if d, err := time.ParseDuration(mrt); err == nil {
+			cfg.maxRetryTime = d
+		}
+	}
+	if js, ok := tmp["jwt_secret"].(string); ok {
+		cfg.jwtSecret = js
+	}
+	if tt, ok := tmp["tenant_tag"].(string); ok {
+		cfg.tenantTag = tt
+	}
+	if qt, ok := tmp["query_timeout"].(string); ok && qt != "" {
+		if d, err := time.ParseDuration(qt); err == nil {
+			cfg.queryTimeout = d
+		}
+	}
+	if mr, ok := tmp["max_rows"].(float64); ok && mr > 0 {
+		cfg.maxRows = int(mr)
+	}
+	if exporters, ok := tmp["exporters"].([]interface{}); ok {
+		for _, e := range exporters {
+			if s, ok := e.(string); ok {
+				cfg.exporters = append(cfg.exporters, s)
+			}
+		}
+	}
+	if pp, ok := tmp["prometheus_path"].(string); ok && pp != "" {
+		cfg.prometheusPath = pp
+	}
+	if oe, ok := tmp["otlp_endpoint"].(string); ok {
+		cfg.otlpEndpoint = oe
+	}
+	if op, ok := tmp["otlp_protocol"].(string); ok && op != "" {
+		cfg.otlpProtocol = op
+	}
+	if oi, ok := tmp["otlp_insecure"].(bool); ok {
+		cfg.otlpInsecure = oi
+	}
+	if kb, ok := tmp["kafka_brokers"].([]interface{}); ok {
+		for _, b := range kb {
+			if s, ok := b.(string); ok {
+				cfg.kafkaBrokers = append(cfg.kafkaBrokers, s)
+			}
+		}
+	}
+	if kt, ok := tmp["kafka_topic"].(string); ok {
+		cfg.kafkaTopic = kt
+	}
+	if rules, ok := tmp["tag_rules"].([]interface{}); ok {
+		for _, r := range rules {
+			rule, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			pattern, _ := rule["pattern"].(string)
+			if pattern == "" {
+				continue
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				continue
+			}
+			key, _ := rule["key"].(string)
+			replacement, _ := rule["replacement"].(string)
+			cfg.tagRules = append(cfg.tagRules, tagRule{key: key, pattern: re, replacement: replacement})
+		}
+	}
+	if cc, ok := tmp["cardinality_ceiling"].(float64); ok && cc > 0 {
+		cfg.cardinalityCeiling = int(cc)
+	}
+	if di, ok := tmp["downsample_interval"].(string); ok && di != "" {
+		if d, err := time.ParseDuration(di); err == nil {
+			cfg.downsampleInterval = d
+		}
+	}
+
+	return cfg
+}
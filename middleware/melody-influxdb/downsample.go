@@ -0,0 +1,130 @@
+package influxdb
+
+import (
+	"sync"
+	"time"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+// downsamplerMaxSeries bounds how many distinct series the downsampler
+// holds open bucket state for. A cardinality explosion should be caught by
+// the cardinality guard first, not leave this guard holding one entry per
+// series forever.
+const downsamplerMaxSeries = 100000
+
+// bucketState accumulates one series' samples for the bucket currently
+// being filled, so it can be folded into a single point once the bucket
+// closes instead of forwarding (or silently dropping) every sample.
+type bucketState struct {
+	bucket time.Time
+	kind   sink.Kind
+	sums   map[string]float64
+	counts map[string]int
+}
+
+// downsampler cuts write volume for long-retention buckets by aggregating
+// every point for the same series that falls in the same interval bucket
+// (e.g. 1m) into a single point, instead of forwarding one per collector
+// tick. Counter fields are summed across the bucket so a per-interval
+// delta isn't undercounted; gauge and histogram fields are averaged.
+type downsampler struct {
+	mu       sync.Mutex
+	interval time.Duration
+	buckets  *boundedLRU // seriesKey -> *bucketState
+}
+
+func newDownsampler(interval time.Duration) *downsampler {
+	return &downsampler{
+		interval: interval,
+		buckets:  newBoundedLRU(downsamplerMaxSeries),
+	}
+}
+
+// Admit folds p into the running aggregate for seriesKey's current bucket.
+// It reports whether p was just rewritten in place to the previous
+// bucket's finished aggregate and should be forwarded; points folded into
+// a still-open bucket are held back until that bucket closes.
+func (d *downsampler) Admit(seriesKey string, p *sink.Point) bool {
+	if d.interval <= 0 {
+		return true
+	}
+
+	bucket := p.Time.Truncate(d.interval)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cur, _ := d.buckets.Get(seriesKey)
+	state, _ := cur.(*bucketState)
+
+	if state == nil {
+		d.buckets.Set(seriesKey, newBucketState(bucket, p))
+		return false
+	}
+
+	if !bucket.After(state.bucket) {
+		state.merge(p)
+		return false
+	}
+
+	// Capture p's own values into the new bucket before finish overwrites
+	// p.Fields with the previous bucket's aggregate for forwarding.
+	next := newBucketState(bucket, p)
+	state.finish(p)
+	d.buckets.Set(seriesKey, next)
+	return true
+}
+
+func newBucketState(bucket time.Time, p *sink.Point) *bucketState {
+	s := &bucketState{
+		bucket: bucket,
+		kind:   p.Kind,
+		sums:   make(map[string]float64, len(p.Fields)),
+		counts: make(map[string]int, len(p.Fields)),
+	}
+	s.merge(p)
+	return s
+}
+
+func (s *bucketState) merge(p *sink.Point) {
+	for k, v := range p.Fields {
+		f, ok := downsampleFloat64(v)
+		if !ok {
+			continue
+		}
+		s.sums[k] += f
+		s.counts[k]++
+	}
+}
+
+// finish rewrites p in place to this bucket's finished aggregate: summed
+// for counters, averaged for everything else. Non-numeric fields (which
+// never made it into sums/counts) are left untouched.
+func (s *bucketState) finish(p *sink.Point) {
+	p.Time = s.bucket
+	for k, sum := range s.sums {
+		if s.kind == sink.KindCounter {
+			p.Fields[k] = sum
+			continue
+		}
+		p.Fields[k] = sum / float64(s.counts[k])
+	}
+}
+
+func downsampleFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	default:
+		return 0, false
+	}
+}
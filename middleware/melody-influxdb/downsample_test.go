@@ -0,0 +1,66 @@
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+func counterPoint(v float64, t time.Time) *sink.Point {
+	return &sink.Point{
+		Measurement: "requests",
+		Fields:      map[string]interface{}{"count": v},
+		Time:        t,
+		Kind:        sink.KindCounter,
+	}
+}
+
+func TestDownsamplerSumsCountersAcrossABucket(t *testing.T) {
+	d := newDownsampler(time.Minute)
+	base := time.Unix(0, 0)
+
+	if d.Admit("series", counterPoint(1, base)) {
+		t.Fatalf("first point ever seen should be held back, not forwarded")
+	}
+	if d.Admit("series", counterPoint(2, base.Add(10*time.Second))) {
+		t.Fatalf("second point in the same bucket should be held back, not forwarded")
+	}
+	if d.Admit("series", counterPoint(3, base.Add(20*time.Second))) {
+		t.Fatalf("third point in the same bucket should be held back, not forwarded")
+	}
+
+	p := counterPoint(4, base.Add(time.Minute))
+	if !d.Admit("series", p) {
+		t.Fatalf("first point of the next bucket should forward the previous bucket's aggregate")
+	}
+	if got := p.Fields["count"]; got != float64(6) {
+		t.Fatalf("aggregated counter = %v, want 6 (1+2+3 summed)", got)
+	}
+}
+
+func TestDownsamplerAveragesGauges(t *testing.T) {
+	d := newDownsampler(time.Minute)
+	base := time.Unix(0, 0)
+	gauge := func(v float64, t time.Time) *sink.Point {
+		return &sink.Point{Measurement: "mem", Fields: map[string]interface{}{"used": v}, Time: t, Kind: sink.KindGauge}
+	}
+
+	d.Admit("series", gauge(10, base))
+	d.Admit("series", gauge(20, base.Add(10*time.Second)))
+
+	p := gauge(0, base.Add(time.Minute))
+	if !d.Admit("series", p) {
+		t.Fatalf("first point of the next bucket should forward the previous bucket's aggregate")
+	}
+	if got := p.Fields["used"]; got != float64(15) {
+		t.Fatalf("aggregated gauge = %v, want 15 (mean of 10 and 20)", got)
+	}
+}
+
+func TestDownsamplerDisabledForwardsEveryPoint(t *testing.T) {
+	d := newDownsampler(0)
+	if !d.Admit("series", counterPoint(1, time.Unix(0, 0))) {
+		t.Fatalf("Admit() = false with downsampling disabled, want true")
+	}
+}
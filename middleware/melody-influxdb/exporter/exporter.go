@@ -0,0 +1,24 @@
+// Package exporter lets the InfluxDB middleware hand the same collected
+// points to several metrics backends at once, instead of being hard-wired
+// to InfluxDB alone.
+package exporter
+
+import (
+	"context"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+// Exporter is implemented by every supported metrics-export backend
+// (InfluxDB, Prometheus, OTLP, Kafka, ...). Register builds one per entry
+// in extra_config's "exporters" list and updateAndSendData feeds the
+// collected points, after the guardrails and any downsampling, to all of
+// them.
+type Exporter interface {
+	// Export hands a batch of points to the exporter. Implementations that
+	// can't keep up should drop rather than block the caller.
+	Export(ctx context.Context, points []*sink.Point) error
+
+	// Close releases any resources held by the exporter.
+	Close() error
+}
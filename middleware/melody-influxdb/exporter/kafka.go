@@ -0,0 +1,56 @@
+package exporter
+
+import (
+	"context"
+
+	"github.com/Shopify/sarama"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+// KafkaConfig selects the brokers and topic line-protocol messages are
+// produced to, for downstream Telegraf/consumers to pick up.
+type KafkaConfig struct {
+	Brokers []string
+	Topic   string
+}
+
+// kafkaExporter emits one line-protocol message per point, so any Telegraf
+// instance (or other consumer) already speaking that format can subscribe
+// without the middleware knowing anything about its schema.
+type kafkaExporter struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+// NewKafkaExporter builds an Exporter that produces to the given brokers
+// using sarama's synchronous producer, so a failed send surfaces to the
+// caller the same way the other exporters do.
+func NewKafkaExporter(cfg KafkaConfig) (Exporter, error) {
+	conf := sarama.NewConfig()
+	conf.Producer.Return.Successes = true
+
+	producer, err := sarama.NewSyncProducer(cfg.Brokers, conf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kafkaExporter{producer: producer, topic: cfg.Topic}, nil
+}
+
+func (e *kafkaExporter) Export(_ context.Context, points []*sink.Point) error {
+	for _, p := range points {
+		msg := &sarama.ProducerMessage{
+			Topic: e.topic,
+			Value: sarama.StringEncoder(sink.LineProtocol(p)),
+		}
+		if _, _, err := e.producer.SendMessage(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *kafkaExporter) Close() error {
+	return e.producer.Close()
+}
@@ -0,0 +1,109 @@
+package exporter
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+// OTLPConfig selects the collector endpoint and transport for the OTLP
+// exporter. Protocol is "grpc" (the default) or "http".
+type OTLPConfig struct {
+	Endpoint string
+	Protocol string
+	Insecure bool
+}
+
+// otlpExporter pushes every collected point to an OTLP collector as a
+// gauge metric, leaving aggregation/retention to whatever backend sits
+// behind the collector.
+type otlpExporter struct {
+	exporter metric.Exporter
+	res      *resource.Resource
+}
+
+// NewOTLPExporter builds an Exporter that pushes to an OTLP gRPC or HTTP
+// collector endpoint.
+func NewOTLPExporter(ctx context.Context, cfg OTLPConfig) (Exporter, error) {
+	var (
+		exp metric.Exporter
+		err error
+	)
+
+	if cfg.Protocol == "http" {
+		opts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		exp, err = otlpmetrichttp.New(ctx, opts...)
+	} else {
+		opts := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlpmetricgrpc.WithInsecure())
+		}
+		exp, err = otlpmetricgrpc.New(ctx, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &otlpExporter{
+		exporter: exp,
+		res:      resource.Default(),
+	}, nil
+}
+
+func (e *otlpExporter) Export(ctx context.Context, points []*sink.Point) error {
+	metrics := make([]metricdata.Metrics, 0, len(points))
+	for _, p := range points {
+		attrs := attributesFromTags(p.Tags)
+
+		for field, v := range p.Fields {
+			val, ok := toFloat64(v)
+			if !ok {
+				continue
+			}
+
+			metrics = append(metrics, metricdata.Metrics{
+				Name: p.Measurement + "_" + field,
+				Data: metricdata.Gauge[float64]{
+					DataPoints: []metricdata.DataPoint[float64]{{
+						Attributes: attrs,
+						Time:       p.Time,
+						Value:      val,
+					}},
+				},
+			})
+		}
+	}
+
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	return e.exporter.Export(ctx, &metricdata.ResourceMetrics{
+		Resource: e.res,
+		ScopeMetrics: []metricdata.ScopeMetrics{
+			{Metrics: metrics},
+		},
+	})
+}
+
+func (e *otlpExporter) Close() error {
+	return e.exporter.Shutdown(context.Background())
+}
+
+func attributesFromTags(tags map[string]string) attribute.Set {
+	kvs := make([]attribute.KeyValue, 0, len(tags))
+	for k, v := range tags {
+		kvs = append(kvs, attribute.String(k, v))
+	}
+	return attribute.NewSet(kvs...)
+}
@@ -0,0 +1,127 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+var invalidPromChar = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// PrometheusExporter keeps the most recent value of every
+// measurement/field/tag-set combination in its own registry, ready to be
+// scraped from the endpoint mounted by Handler.
+type PrometheusExporter struct {
+	registry *prometheus.Registry
+
+	mu     sync.Mutex
+	gauges map[string]*prometheus.GaugeVec
+}
+
+// NewPrometheusExporter creates an exporter with its own registry, so it
+// never collides with metrics the host service registers elsewhere.
+func NewPrometheusExporter() *PrometheusExporter {
+	return &PrometheusExporter{
+		registry: prometheus.NewRegistry(),
+		gauges:   make(map[string]*prometheus.GaugeVec),
+	}
+}
+
+// Handler serves the current values in Prometheus's text exposition format.
+func (e *PrometheusExporter) Handler() http.Handler {
+	return promhttp.HandlerFor(e.registry, promhttp.HandlerOpts{})
+}
+
+func (e *PrometheusExporter) Export(_ context.Context, points []*sink.Point) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var firstErr error
+
+	for _, p := range points {
+		tagNames, tagValues := sortedTags(p.Tags)
+
+		for field, v := range p.Fields {
+			val, ok := toFloat64(v)
+			if !ok {
+				continue
+			}
+
+			name := promName(p.Measurement, field)
+			key := name + "|" + strings.Join(tagNames, ",")
+
+			gv, ok := e.gauges[key]
+			if !ok {
+				candidate := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name}, tagNames)
+				if err := e.registry.Register(candidate); err != nil {
+					// Another tag-key set already registered this same
+					// measurement/field name with different label
+					// dimensions; drop this point's sample instead of
+					// crashing the exporter goroutine over it.
+					if firstErr == nil {
+						firstErr = err
+					}
+					continue
+				}
+				gv = candidate
+				e.gauges[key] = gv
+			}
+
+			gv.WithLabelValues(tagValues...).Set(val)
+		}
+	}
+
+	return firstErr
+}
+
+func (e *PrometheusExporter) Close() error {
+	return nil
+}
+
+func promName(measurement, field string) string {
+	return invalidPromChar.ReplaceAllString(measurement+"_"+field, "_")
+}
+
+func sortedTags(tags map[string]string) (names, values []string) {
+	names = make([]string, 0, len(tags))
+	for k := range tags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	values = make([]string, 0, len(names))
+	for _, k := range names {
+		values = append(values, tags[k])
+	}
+	return names, values
+}
+
+func toFloat64(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case bool:
+		if n {
+			return 1, true
+		}
+		return 0, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,41 @@
+package exporter
+
+import (
+	"context"
+	"testing"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+func TestPrometheusExporterExport(t *testing.T) {
+	e := NewPrometheusExporter()
+
+	err := e.Export(context.Background(), []*sink.Point{
+		{Measurement: "cpu", Tags: map[string]string{"host": "a"}, Fields: map[string]interface{}{"usage": 1.0}},
+	})
+	if err != nil {
+		t.Fatalf("Export() error = %v, want nil", err)
+	}
+}
+
+func TestPrometheusExporterDimensionCollisionDoesNotPanic(t *testing.T) {
+	e := NewPrometheusExporter()
+
+	// Same measurement+field, but two different tag-key sets, so they
+	// collapse onto the same Prometheus metric name with incompatible
+	// label dimensions.
+	points := []*sink.Point{
+		{Measurement: "cpu", Tags: map[string]string{"host": "a"}, Fields: map[string]interface{}{"usage": 1.0}},
+		{Measurement: "cpu", Tags: map[string]string{"host": "a", "region": "us"}, Fields: map[string]interface{}{"usage": 2.0}},
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Export() panicked: %v", r)
+		}
+	}()
+
+	if err := e.Export(context.Background(), points); err == nil {
+		t.Fatalf("Export() error = nil, want a collision error for the second point's label set")
+	}
+}
@@ -0,0 +1,65 @@
+package influxdb
+
+import (
+	"context"
+
+	"melody/logging"
+	"melody/middleware/melody-influxdb/exporter"
+	"melody/middleware/melody-influxdb/sink"
+)
+
+// influxSinkExporter adapts the existing async writer/sink pipeline (batching,
+// retries, the ring buffer) to the generic exporter.Exporter interface, so
+// InfluxDB is just one exporter among however many extra_config configures.
+type influxSinkExporter struct {
+	writer *asyncWriter
+}
+
+func (e *influxSinkExporter) Export(_ context.Context, points []*sink.Point) error {
+	e.writer.Enqueue(points...)
+	return nil
+}
+
+func (e *influxSinkExporter) Close() error {
+	return nil
+}
+
+// buildExporters returns the InfluxDB sink exporter plus whichever extra
+// backends cfg.exporters names. An unknown name is logged and skipped
+// rather than aborting Register, so a typo in one exporter's config
+// doesn't take the others down with it.
+func buildExporters(ctx context.Context, cfg influxdbConfig, writer *asyncWriter, logger logging.Logger) []exporter.Exporter {
+	exporters := []exporter.Exporter{&influxSinkExporter{writer: writer}}
+
+	for _, name := range cfg.exporters {
+		switch name {
+		case "prometheus":
+			exporters = append(exporters, exporter.NewPrometheusExporter())
+		case "otlp":
+			exp, err := exporter.NewOTLPExporter(ctx, exporter.OTLPConfig{
+				Endpoint: cfg.otlpEndpoint,
+				Protocol: cfg.otlpProtocol,
+				Insecure: cfg.otlpInsecure,
+			})
+			if err != nil {
+				logger.Error("unable to build the otlp exporter,", err.Error())
+				continue
+			}
+			exporters = append(exporters, exp)
+		case "kafka":
+			exp, err := exporter.NewKafkaExporter(exporter.KafkaConfig{
+				Brokers: cfg.kafkaBrokers,
+				Topic:   cfg.kafkaTopic,
+			})
+			if err != nil {
+				logger.Error("unable to build the kafka exporter,", err.Error())
+				continue
+			}
+			exporters = append(exporters, exp)
+		default:
+			logger.Error("unknown exporter", name, "ignored")
+		}
+	}
+
+	return exporters
+}
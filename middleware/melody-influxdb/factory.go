@@ -0,0 +1,30 @@
+package influxdb
+
+import "melody/middleware/melody-influxdb/sink"
+
+// newSink builds the write destination selected by cfg.driver. v1 is the
+// default so existing deployments keep writing to InfluxDB 1.x without
+// touching their config.
+func newSink(cfg influxdbConfig) (sink.Sink, error) {
+	switch cfg.driver {
+	case driverV2:
+		return sink.NewV2Sink(sink.V2Config{
+			Address:            cfg.address,
+			Token:              cfg.token,
+			Org:                cfg.org,
+			Bucket:             cfg.bucket,
+			Timeout:            cfg.timeout,
+			InsecureSkipVerify: cfg.insecureSkipVerify,
+		})
+	case driverFile:
+		return sink.NewFileSink(sink.FileConfig{Path: cfg.filePath})
+	default:
+		return sink.NewV1Sink(sink.V1Config{
+			Address:  cfg.address,
+			Username: cfg.username,
+			Password: cfg.password,
+			Database: cfg.db,
+			Timeout:  cfg.timeout,
+		})
+	}
+}
@@ -9,9 +9,11 @@ import (
 	"melody/logging"
 	alert "melody/middleware/melody-alert"
 	"melody/middleware/melody-influxdb/counter"
+	"melody/middleware/melody-influxdb/exporter"
 	"melody/middleware/melody-influxdb/gauge"
 	"melody/middleware/melody-influxdb/histogram"
 	"melody/middleware/melody-influxdb/middleware"
+	"melody/middleware/melody-influxdb/sink"
 	"melody/middleware/melody-influxdb/ws"
 	ginmetrics "melody/middleware/melody-metrics/gin"
 	"net/http"
@@ -20,12 +22,14 @@ import (
 	"time"
 )
 
-var (
-	pingTimeOut = time.Second
-)
-
 type clientWrapper struct {
 	client     client.Client
+	sink       sink.Sink
+	writer     *asyncWriter
+	exporters  []exporter.Exporter
+	prometheus *exporter.PrometheusExporter
+	guardrails *guardrails
+	bus        *ws.Bus
 	collection *ginmetrics.Metrics
 	logger     logging.Logger
 	config     influxdbConfig
@@ -40,36 +44,51 @@ func Register(ctx context.Context, cfg *config.ServiceConfig, metrics *ginmetric
 		return configErr
 	}
 
-	influxClient, err := client.NewHTTPClient(client.HTTPConfig{
-		Addr:     config.address,
-		Username: config.username,
-		Password: config.password,
-		Timeout:  config.timeout,
-	})
-
+	s, err := newSink(config)
 	if err != nil {
-		logger.Debug("create influx client err")
+		logger.Error("unable to build the", config.driver, "sink,", err.Error())
 		return err
 	}
 
-	// 检察influx server是否宕机
-	duration, msg, err := influxClient.Ping(pingTimeOut)
-	if err != nil {
-		logger.Error("unable to ping influx server,", err.Error())
-		return err
+	// InfluxQL queries (on /query and over the websocket) go through a v1
+	// client, which is only built for the v1 driver; Flux queries on /query
+	// build their own v2 client instead.
+	var influxClient client.Client
+	if config.driver == driverV1 {
+		influxClient, _ = client.NewHTTPClient(client.HTTPConfig{
+			Addr:     config.address,
+			Username: config.username,
+			Password: config.password,
+			Timeout:  config.timeout,
+		})
 	}
-	logger.Debug("ping success to influx server with duration:", duration, " and message:", msg)
 
 	t := time.NewTicker(config.ttl)
+	buf := NewBuffer(config.bufferSize, config.bufferMaxBytes)
+	writer := newAsyncWriter(config, s, buf, logger)
+	exporters := buildExporters(ctx, config, writer, logger)
 
 	clientWrapper := &clientWrapper{
 		client:     influxClient,
+		sink:       s,
+		writer:     writer,
+		exporters:  exporters,
+		guardrails: newGuardrails(config),
+		bus:        ws.NewBus(),
 		collection: metrics,
 		logger:     logger,
 		config:     config,
-		buf:        NewBuffer(config.bufferSize),
+		buf:        buf,
 	}
 
+	for _, exp := range exporters {
+		if prom, ok := exp.(*exporter.PrometheusExporter); ok {
+			clientWrapper.prometheus = prom
+		}
+	}
+
+	go clientWrapper.writer.Run(ctx)
+
 	if config.dataServerEnable {
 		ws.RegisterWSTimeControl()
 		// Create melody data server
@@ -77,6 +96,16 @@ func Register(ctx context.Context, cfg *config.ServiceConfig, metrics *ginmetric
 
 		// Create melody data websocket server
 		clientWrapper.runWebSocketServer(ctx, cfg, logger)
+	} else if influxClient != nil {
+		// Nothing in this process uses influxClient outside the data server
+		// above, so with it disabled there is no in-flight query to race by
+		// closing the client as soon as ctx is canceled.
+		go func() {
+			<-ctx.Done()
+			if err := influxClient.Close(); err != nil {
+				logger.Error("closing the influxql client on shutdown error:", err.Error())
+			}
+		}()
 	}
 
 	checker, err := alert.NewChecker(cfg)
@@ -99,11 +128,14 @@ func (cw *clientWrapper) runWebSocketServer(ctx context.Context, cfg *config.Ser
 	}
 
 	wsc := ws.WebSocketClient{
-		Client:   cw.client,
-		Upgrader: upgrader,
-		Logger:   cw.logger,
-		DB:       cw.config.db,
-		Cfg:      cfg,
+		Client:    cw.client,
+		Upgrader:  upgrader,
+		Logger:    cw.logger,
+		DB:        cw.config.db,
+		Cfg:       cfg,
+		JWTSecret: cw.config.jwtSecret,
+		TenantTag: cw.config.tenantTag,
+		Bus:       cw.bus,
 	}
 
 	wsc.RegisterHandleFunc()
@@ -135,6 +167,16 @@ func (cw *clientWrapper) runEndpoint(ctx context.Context, engine *gin.Engine, lo
 		c, cancel := context.WithTimeout(ctx, time.Second)
 		server.Shutdown(c)
 		cancel()
+
+		// cw.client is the v1 InfluxQL client the /query handler above and
+		// the websocket query op share; wait for the REST server's graceful
+		// shutdown to finish before closing it, so an in-flight /query
+		// request isn't torn down mid-request.
+		if cw.client != nil {
+			if err := cw.client.Close(); err != nil {
+				logger.Error("closing the influxql client on shutdown error:", err.Error())
+			}
+		}
 	}()
 }
 
@@ -150,8 +192,15 @@ func (cw *clientWrapper) newEngine(cfg *config.ServiceConfig) *gin.Engine {
 	engine.HandleMethodNotAllowed = true
 	engine.Use(middleware.Cors())
 	engine.POST("/ping", cw.Ping())
+	if cw.prometheus != nil {
+		engine.GET(cw.config.prometheusPath, gin.WrapH(cw.prometheus.Handler()))
+	}
 	if cw.config.dataServerQueryEnable {
-		engine.POST("/query", cw.Query())
+		queryGroup := engine.Group("/query")
+		if cw.config.jwtSecret != "" {
+			queryGroup.Use(middleware.BearerAuth(cw.config.jwtSecret, cw.config.tenantTag))
+		}
+		queryGroup.POST("", cw.Query())
 	}
 	engine.POST("/time", cw.ModifyTimeControl())
 	engine.POST("/backends", cw.Backends(cfg))
@@ -182,48 +231,48 @@ func (cw *clientWrapper) updateAndSendData(ctx context.Context, ticker <-chan ti
 			continue
 		}
 
-		bp, _ := client.NewBatchPoints(client.BatchPointsConfig{
-			Precision: "s",
-			Database:  cw.config.db,
-		})
 		now := time.Unix(0, snapshot.Time)
 
+		var points []*sink.Point
 		for _, p := range counter.Points(hostname, now, snapshot.Counters, cw.logger, checker) {
-			bp.AddPoint(p)
+			points = append(points, toSinkPoint(p, sink.KindCounter))
 		}
-
 		for _, p := range gauge.Points(hostname, now, snapshot.Gauges, cw.logger, checker) {
-			bp.AddPoint(p)
+			points = append(points, toSinkPoint(p, sink.KindGauge))
 		}
-
 		for _, p := range histogram.Points(hostname, now, snapshot.Histograms, cw.logger, checker) {
-			bp.AddPoint(p)
-		}
-
-		if err := cw.client.Write(bp); err != nil {
-			cw.logger.Error("writing to influx server error:", err.Error())
-			cw.buf.Add(bp)
-			continue
+			points = append(points, toSinkPoint(p, sink.KindHistogram))
 		}
 
-		cw.logger.Info(len(bp.Points()), "datapoints sent to Influx")
+		points = cw.guardrails.Apply(points)
 
-		var pts []*client.Point
-		bpPending := cw.buf.Elements()
-		for _, failedBP := range bpPending {
-			pts = append(pts, failedBP.Points()...)
+		for _, p := range points {
+			cw.bus.Publish(p)
 		}
 
-		retryBatch, _ := client.NewBatchPoints(client.BatchPointsConfig{
-			Database:  cw.config.db,
-			Precision: "s",
-		})
-		retryBatch.AddPoints(pts)
+		// Downsampling only throttles what reaches the exporters; the bus
+		// above already got every point at full rate.
+		downsampled := cw.guardrails.Downsample(points)
 
-		if err := cw.client.Write(retryBatch); err != nil {
-			cw.logger.Error("writing to influx:", err.Error())
-			cw.buf.Add(bpPending...)
-			continue
+		for _, exp := range cw.exporters {
+			if err := exp.Export(ctx, downsampled); err != nil {
+				cw.logger.Error("exporter error:", err.Error())
+			}
 		}
 	}
 }
+
+// toSinkPoint adapts a v1 client.Point, as produced by the untouched
+// counter/gauge/histogram packages, into the backend-agnostic sink.Point.
+// kind records which of those packages built p, so the downsample guard
+// knows whether to sum or average it.
+func toSinkPoint(p *client.Point, kind sink.Kind) *sink.Point {
+	fields, _ := p.Fields()
+	return &sink.Point{
+		Measurement: p.Name(),
+		Tags:        p.Tags(),
+		Fields:      fields,
+		Time:        p.Time(),
+		Kind:        kind,
+	}
+}
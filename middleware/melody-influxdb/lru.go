@@ -0,0 +1,61 @@
+package influxdb
+
+import "container/list"
+
+// boundedLRU is a fixed-capacity, least-recently-used cache keyed by
+// string, shared by the downsample and cardinality guards so neither one
+// grows a bare map without bound when it's tracking one entry per distinct
+// series.
+type boundedLRU struct {
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type lruEntry struct {
+	key   string
+	value interface{}
+}
+
+// newBoundedLRU creates a cache that holds at most capacity entries.
+// capacity <= 0 disables eviction.
+func newBoundedLRU(capacity int) *boundedLRU {
+	return &boundedLRU{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns key's value and marks it most recently used.
+func (c *boundedLRU) Get(key string) (interface{}, bool) {
+	el, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return el.Value.(*lruEntry).value, true
+}
+
+// Set inserts or updates key's value, marks it most recently used, and
+// evicts the least recently used entry if the cache is now over capacity.
+func (c *boundedLRU) Set(key string, value interface{}) {
+	if el, ok := c.items[key]; ok {
+		el.Value.(*lruEntry).value = value
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value})
+
+	if c.capacity > 0 && c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len reports how many entries are currently cached.
+func (c *boundedLRU) Len() int {
+	return c.ll.Len()
+}
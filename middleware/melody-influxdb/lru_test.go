@@ -0,0 +1,36 @@
+package influxdb
+
+import "testing"
+
+func TestBoundedLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newBoundedLRU(2)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	// Touch "a" so "b" becomes the least recently used entry.
+	c.Get("a")
+	c.Set("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Fatalf("Get(\"b\") found an entry that should have been evicted")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Fatalf("Get(\"a\") missing, should have survived the eviction")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Fatalf("Get(\"c\") missing, should have just been inserted")
+	}
+	if got := c.Len(); got != 2 {
+		t.Fatalf("Len() = %d, want 2", got)
+	}
+}
+
+func TestBoundedLRUUnboundedWhenCapacityIsZero(t *testing.T) {
+	c := newBoundedLRU(0)
+	for i := 0; i < 1000; i++ {
+		c.Set(string(rune(i)), i)
+	}
+	if got := c.Len(); got != 1000 {
+		t.Fatalf("Len() = %d, want 1000 with eviction disabled", got)
+	}
+}
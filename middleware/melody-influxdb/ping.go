@@ -0,0 +1,31 @@
+package influxdb
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pingResponse reports the data server's health plus the internal metrics an
+// operator needs to notice metrics loss without tailing logs.
+type pingResponse struct {
+	Status        string `json:"status"`
+	Driver        string `json:"driver"`
+	DroppedPoint  uint64 `json:"dropped_points"`
+	DroppedSeries uint64 `json:"melody_dropped_series"`
+}
+
+// Ping reports that the data server is alive, along with how many points
+// have been dropped by the writer's ring buffer because of a sustained
+// InfluxDB outage, and how many points have been collapsed into an
+// overflow bucket by the cardinality guard.
+func (cw *clientWrapper) Ping() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, pingResponse{
+			Status:        "ok",
+			Driver:        cw.config.driver,
+			DroppedPoint:  cw.writer.Dropped(),
+			DroppedSeries: cw.guardrails.Dropped(),
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package influxdb
+
+import (
+	"sort"
+	"strings"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+// guardrails applies tag normalization, cardinality collapsing and, if
+// configured, downsampling to a batch of freshly collected points. Apply
+// runs once per updateAndSendData tick, ahead of the existing publish/export
+// loop, so none of that downstream code needs to know the guardrails exist.
+//
+// Downsampling is deliberately kept out of Apply: it only throttles how
+// often a series is written to the configured exporters, and must not also
+// throttle the live websocket bus, or setting downsample_interval would
+// delay realtime subscribers by a full interval too. Callers run Apply once
+// and feed its result to the bus as-is, then call Downsample separately for
+// whatever they hand to exporters.
+type guardrails struct {
+	tagRules    []tagRule
+	cardinality *cardinalityGuard
+	downsample  *downsampler
+}
+
+func newGuardrails(cfg influxdbConfig) *guardrails {
+	return &guardrails{
+		tagRules:    cfg.tagRules,
+		cardinality: newCardinalityGuard(cfg.cardinalityCeiling),
+		downsample:  newDownsampler(cfg.downsampleInterval),
+	}
+}
+
+// Apply filters and rewrites points in place, returning the subset that
+// should still be forwarded downstream. It does not downsample; see
+// Downsample.
+func (g *guardrails) Apply(points []*sink.Point) []*sink.Point {
+	kept := points[:0]
+
+	for _, p := range points {
+		normalizeTags(p.Tags, g.tagRules)
+
+		if !g.cardinality.Admit(p.Measurement, seriesKey(p.Tags)) {
+			collapseToOverflow(p)
+		}
+
+		kept = append(kept, p)
+	}
+
+	return kept
+}
+
+// Downsample folds points into per-interval aggregates for whichever
+// exporters get the result, without touching the points passed to it: each
+// admitted point is rewritten on a clone, so a caller that already handed
+// the same points to the live bus can't have them mutated out from under a
+// concurrently reading subscriber goroutine.
+func (g *guardrails) Downsample(points []*sink.Point) []*sink.Point {
+	kept := make([]*sink.Point, 0, len(points))
+
+	for _, p := range points {
+		clone := clonePoint(p)
+		if !g.downsample.Admit(clone.Measurement+"|"+seriesKey(clone.Tags), clone) {
+			continue
+		}
+		kept = append(kept, clone)
+	}
+
+	return kept
+}
+
+// clonePoint copies p shallowly enough that downsampler.Admit can rewrite
+// the clone's Fields and Time in place without reaching back into p's own
+// maps.
+func clonePoint(p *sink.Point) *sink.Point {
+	fields := make(map[string]interface{}, len(p.Fields))
+	for k, v := range p.Fields {
+		fields[k] = v
+	}
+
+	return &sink.Point{
+		Measurement: p.Measurement,
+		Tags:        p.Tags,
+		Fields:      fields,
+		Time:        p.Time,
+		Kind:        p.Kind,
+	}
+}
+
+// Dropped reports how many points have been collapsed into the overflow
+// bucket (see cardinalityGuard.Dropped). It is surfaced as the
+// melody_dropped_series counter.
+func (g *guardrails) Dropped() uint64 {
+	return g.cardinality.Dropped()
+}
+
+// collapseToOverflow rewrites every tag value on p to the overflow sentinel
+// once its measurement is over its cardinality ceiling, so the point still
+// gets written, merged into a single catch-all series instead of minting a
+// new one.
+func collapseToOverflow(p *sink.Point) {
+	for k := range p.Tags {
+		p.Tags[k] = cardinalityOverflowValue
+	}
+}
+
+// seriesKey builds a stable identifier for a point's tag set so the same
+// series always hashes to the same key regardless of map iteration order.
+func seriesKey(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(tags[k])
+		b.WriteByte(',')
+	}
+
+	return b.String()
+}
@@ -0,0 +1,180 @@
+package influxdb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	client "github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+
+	"melody/middleware/melody-influxdb/scope"
+)
+
+const (
+	languageInfluxQL = "influxql"
+	languageFlux     = "flux"
+)
+
+// queryRequest is the body accepted by POST /query. Language defaults to
+// influxql for backwards compatibility with dashboards built against the
+// v1-only endpoint.
+type queryRequest struct {
+	Language string `json:"language"`
+	Query    string `json:"query"`
+}
+
+// Query proxies an InfluxQL or Flux query to InfluxDB, scoping it to the
+// caller's tenant (taken off the JWT validated by middleware.BearerAuth) and
+// bounding both how long it can run and how many rows it can return. Only
+// with tenant scoping enabled are InfluxQL queries limited to SELECT
+// statements; with tenant_tag unset the query is passed through unchecked.
+func (cw *clientWrapper) Query() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req queryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.Language == "" {
+			req.Language = languageInfluxQL
+		}
+
+		tenant := c.GetString("tenant")
+		scoped, err := scopeQuery(req.Language, req.Query, cw.config.tenantTag, cw.config.bucket, tenant)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), cw.config.queryTimeout)
+		defer cancel()
+
+		var (
+			rows interface{}
+			qErr error
+		)
+		switch req.Language {
+		case languageFlux:
+			rows, qErr = cw.runFluxQuery(ctx, scoped)
+		default:
+			rows, qErr = cw.runInfluxQLQuery(ctx, scoped)
+		}
+
+		if qErr != nil {
+			cw.logger.Error("query proxy error:", qErr.Error())
+			c.JSON(http.StatusBadGateway, gin.H{"error": qErr.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"results": rows})
+	}
+}
+
+func (cw *clientWrapper) runInfluxQLQuery(ctx context.Context, q string) (interface{}, error) {
+	if cw.client == nil {
+		return nil, fmt.Errorf("no InfluxQL (v1) client configured")
+	}
+
+	type result struct {
+		resp *client.Response
+		err  error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		resp, err := cw.client.Query(client.Query{
+			Command:  q,
+			Database: cw.config.db,
+		})
+		done <- result{resp, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		if r.err != nil {
+			return nil, r.err
+		}
+		if r.resp.Error() != nil {
+			return nil, r.resp.Error()
+		}
+		return limitInfluxQLRows(r.resp.Results, cw.config.maxRows), nil
+	}
+}
+
+func (cw *clientWrapper) runFluxQuery(ctx context.Context, q string) (interface{}, error) {
+	if cw.config.token == "" || cw.config.org == "" {
+		return nil, fmt.Errorf("no Flux (v2) org/token configured")
+	}
+
+	v2 := influxdb2.NewClient(cw.config.address, cw.config.token)
+	defer v2.Close()
+
+	result, err := v2.QueryAPI(cw.config.org).Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	rows := make([]map[string]interface{}, 0)
+	for result.Next() && len(rows) < cw.config.maxRows {
+		rows = append(rows, result.Record().Values())
+	}
+	if result.Err() != nil {
+		return nil, result.Err()
+	}
+
+	return rows, nil
+}
+
+func limitInfluxQLRows(results []client.Result, maxRows int) []client.Result {
+	if maxRows <= 0 {
+		return results
+	}
+
+	remaining := maxRows
+	limited := make([]client.Result, len(results))
+	for i, r := range results {
+		series := make([]models.Row, 0, len(r.Series))
+		for _, s := range r.Series {
+			if remaining <= 0 {
+				break
+			}
+			if len(s.Values) > remaining {
+				s.Values = s.Values[:remaining]
+			}
+			remaining -= len(s.Values)
+			series = append(series, s)
+		}
+		limited[i] = client.Result{Series: series, Messages: r.Messages, Err: r.Err}
+	}
+	return limited
+}
+
+// scopeQuery injects a tenant filter into q so that, for a shared InfluxDB
+// instance, one tenant's dashboard can never read another tenant's series.
+// tenantTag being empty disables scoping entirely, e.g. for single-tenant
+// deployments; tenantTag being set with no tenant is rejected rather than
+// silently falling back to an unscoped query.
+func scopeQuery(language, q, tenantTag, bucket, tenant string) (string, error) {
+	if tenantTag == "" {
+		return q, nil
+	}
+	if tenant == "" {
+		return "", fmt.Errorf("query proxy: request is missing the tenant claim required to scope this query")
+	}
+
+	switch language {
+	case languageFlux:
+		return scope.Flux(q, tenantTag, tenant, bucket)
+	case languageInfluxQL:
+		return scope.InfluxQL(q, tenantTag, tenant)
+	default:
+		return "", fmt.Errorf("unsupported query language %q", language)
+	}
+}
@@ -0,0 +1,80 @@
+// Package scope rewrites a client-supplied InfluxQL or Flux query so it can
+// only ever return one tenant's series. It is shared by the REST /query
+// proxy and the websocket query op, so the two transports enforce the same
+// guarantee instead of drifting apart.
+package scope
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/influxdata/influxql"
+)
+
+// InfluxQL rewrites q so every row it can return also satisfies
+// tag = tenant. It parses the statement rather than splicing the filter
+// into the query text, so a client-supplied "OR" in its own WHERE clause
+// can't widen the result past the tenant boundary: the tenant condition is
+// ANDed with the client's condition as a whole, not just the first term of
+// it.
+func InfluxQL(q, tag, tenant string) (string, error) {
+	stmt, err := influxql.ParseStatement(q)
+	if err != nil {
+		return "", fmt.Errorf("scope: invalid influxql query: %w", err)
+	}
+
+	sel, ok := stmt.(*influxql.SelectStatement)
+	if !ok {
+		return "", fmt.Errorf("scope: only SELECT statements may be run through the tenant-scoped proxy")
+	}
+
+	tenantCond := &influxql.BinaryExpr{
+		Op:  influxql.EQ,
+		LHS: &influxql.VarRef{Val: tag},
+		RHS: &influxql.StringLiteral{Val: tenant},
+	}
+
+	if sel.Condition == nil {
+		sel.Condition = tenantCond
+	} else {
+		// Parenthesize the client's condition: AND binds tighter than OR,
+		// so without the parens "tag='t' AND host='a' OR 1=1" would match
+		// every tenant whenever the client's clause contains an OR.
+		sel.Condition = &influxql.BinaryExpr{
+			Op:  influxql.AND,
+			LHS: tenantCond,
+			RHS: &influxql.ParenExpr{Expr: sel.Condition},
+		}
+	}
+
+	return sel.String(), nil
+}
+
+var (
+	fluxFromBucketRe = regexp.MustCompile(`(?i)from\s*\(\s*bucket\s*:\s*"([^"]*)"\s*\)`)
+	fluxRangeRe      = regexp.MustCompile(`(?i)\|>\s*range\([^)]*\)`)
+)
+
+// Flux rewrites q to add a tag = tenant filter, the same guarantee InfluxQL
+// provides above. Unlike InfluxQL, a Flux query fully controls its own data
+// source, so a tag filter alone can't stop a client from pointing from()
+// at another tenant's bucket outright; Flux rejects any query that
+// references a bucket other than bucket.
+func Flux(q, tag, tenant, bucket string) (string, error) {
+	matches := fluxFromBucketRe.FindAllStringSubmatch(q, -1)
+	if len(matches) == 0 {
+		return "", fmt.Errorf(`scope: flux query must start with a from(bucket: "...") call`)
+	}
+	for _, m := range matches {
+		if m[1] != bucket {
+			return "", fmt.Errorf("scope: flux query may not reference bucket %q", m[1])
+		}
+	}
+
+	filter := fmt.Sprintf(`|> filter(fn: (r) => r.%s == "%s")`, tag, tenant)
+	if loc := fluxRangeRe.FindStringIndex(q); loc != nil {
+		return q[:loc[1]] + "\n  " + filter + q[loc[1]:], nil
+	}
+	return strings.TrimRight(q, "\n ") + "\n  " + filter, nil
+}
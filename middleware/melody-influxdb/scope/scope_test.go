@@ -0,0 +1,103 @@
+package scope
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestInfluxQL(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+		// check, if set, receives the rewritten query and reports any
+		// problem with it.
+		check func(t *testing.T, rewritten string)
+	}{
+		{
+			name:  "no existing WHERE clause",
+			query: `SELECT * FROM cpu`,
+			check: func(t *testing.T, rewritten string) {
+				if !strings.Contains(rewritten, `svc = 'tenant-a'`) {
+					t.Errorf("rewritten query %q does not scope to the tenant", rewritten)
+				}
+			},
+		},
+		{
+			name:  "existing WHERE clause is ANDed, not spliced in front of an OR",
+			query: `SELECT * FROM cpu WHERE host='a' OR 1=1`,
+			check: func(t *testing.T, rewritten string) {
+				// The only way this rewrite is safe is if the client's
+				// condition, OR and all, is wrapped in parens before being
+				// ANDed with the tenant clause.
+				if !strings.Contains(rewritten, "AND (") {
+					t.Errorf("rewritten query %q does not parenthesize the client condition", rewritten)
+				}
+			},
+		},
+		{
+			name:    "non-select statements are rejected",
+			query:   `DROP SERIES FROM cpu`,
+			wantErr: true,
+		},
+		{
+			name:    "unparseable queries are rejected",
+			query:   `not influxql at all (`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InfluxQL(tt.query, "svc", "tenant-a")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InfluxQL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.check != nil {
+				tt.check(t, got)
+			}
+		})
+	}
+}
+
+func TestFlux(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		bucket  string
+		wantErr bool
+	}{
+		{
+			name:   "scopes a query against the configured bucket",
+			query:  `from(bucket: "metrics") |> range(start: -1h)`,
+			bucket: "metrics",
+		},
+		{
+			name:    "rejects a query naming a different bucket",
+			query:   `from(bucket: "other-tenant-bucket") |> range(start: -1h)`,
+			bucket:  "metrics",
+			wantErr: true,
+		},
+		{
+			name:    "rejects a query with no from(bucket: ...) at all",
+			query:   `buckets()`,
+			bucket:  "metrics",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Flux(tt.query, "svc", "tenant-a", tt.bucket)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Flux() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !strings.Contains(got, `r.svc == "tenant-a"`) {
+				t.Errorf("rewritten query %q does not scope to the tenant", got)
+			}
+		})
+	}
+}
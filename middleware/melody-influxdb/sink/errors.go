@@ -0,0 +1,5 @@
+package sink
+
+import "errors"
+
+var errPingFailed = errors.New("sink: ping to InfluxDB 2.x server failed")
@@ -0,0 +1,100 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// FileConfig selects where the file sink writes line-protocol output. An
+// empty Path (or "-") writes to stdout, which is handy for debugging a
+// service locally without standing up an InfluxDB instance.
+type FileConfig struct {
+	Path string
+}
+
+// fileSink renders points as InfluxDB line protocol and appends them to a
+// file or stdout. It never fails a write because of the backend being down,
+// which makes it a reasonable default for local/offline debugging.
+type fileSink struct {
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewFileSink builds a Sink that writes line protocol to a file, or to
+// stdout when cfg.Path is empty or "-".
+func NewFileSink(cfg FileConfig) (Sink, error) {
+	if cfg.Path == "" || cfg.Path == "-" {
+		return &fileSink{w: os.Stdout}, nil
+	}
+
+	f, err := os.OpenFile(cfg.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileSink{w: f, closer: f}, nil
+}
+
+func (s *fileSink) Write(_ context.Context, points []*Point) error {
+	for _, p := range points {
+		if _, err := fmt.Fprintln(s.w, LineProtocol(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *fileSink) Flush() error {
+	if f, ok := s.w.(*os.File); ok {
+		return f.Sync()
+	}
+	return nil
+}
+
+func (s *fileSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// LineProtocol renders p in InfluxDB line protocol, the format the file
+// sink and the Kafka exporter both emit for downstream Telegraf consumers.
+func LineProtocol(p *Point) string {
+	var b strings.Builder
+	b.WriteString(p.Measurement)
+
+	tagKeys := make([]string, 0, len(p.Tags))
+	for k := range p.Tags {
+		tagKeys = append(tagKeys, k)
+	}
+	sort.Strings(tagKeys)
+	for _, k := range tagKeys {
+		b.WriteString(",")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(p.Tags[k])
+	}
+
+	b.WriteString(" ")
+
+	fieldKeys := make([]string, 0, len(p.Fields))
+	for k := range p.Fields {
+		fieldKeys = append(fieldKeys, k)
+	}
+	sort.Strings(fieldKeys)
+	for i, k := range fieldKeys {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "%s=%v", k, p.Fields[k])
+	}
+
+	fmt.Fprintf(&b, " %d", p.Time.UnixNano())
+
+	return b.String()
+}
@@ -0,0 +1,35 @@
+package sink
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLineProtocol(t *testing.T) {
+	p := &Point{
+		Measurement: "cpu",
+		Tags:        map[string]string{"host": "a", "region": "us"},
+		Fields:      map[string]interface{}{"usage": 0.5, "idle": 99},
+		Time:        time.Unix(0, 1234),
+	}
+
+	got := LineProtocol(p)
+	want := `cpu,host=a,region=us idle=99,usage=0.5 1234`
+	if got != want {
+		t.Fatalf("LineProtocol() = %q, want %q", got, want)
+	}
+}
+
+func TestLineProtocolNoTags(t *testing.T) {
+	p := &Point{
+		Measurement: "cpu",
+		Fields:      map[string]interface{}{"usage": 0.5},
+		Time:        time.Unix(0, 1234),
+	}
+
+	got := LineProtocol(p)
+	want := `cpu usage=0.5 1234`
+	if got != want {
+		t.Fatalf("LineProtocol() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,51 @@
+// Package sink abstracts the destination that collected metrics points are
+// written to, so the InfluxDB middleware is not hard-wired to a single
+// InfluxDB major version or even to InfluxDB itself.
+package sink
+
+import (
+	"context"
+	"time"
+)
+
+// Kind distinguishes how a point's fields should be combined when the
+// downsample guard folds several samples for the same series into one
+// bucket: counters are summed, since they represent a per-interval delta
+// that would otherwise be undercounted, while gauges and histograms are
+// averaged.
+type Kind int
+
+const (
+	KindGauge Kind = iota
+	KindCounter
+	KindHistogram
+)
+
+// Point is a backend-agnostic representation of a single line-protocol
+// point. Implementations translate it into whatever wire format their
+// backend expects.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Time        time.Time
+	Kind        Kind
+}
+
+// Sink is implemented by every supported write destination (InfluxDB 1.x,
+// InfluxDB 2.x/Cloud, a local file, ...). Register picks an implementation
+// based on the configured driver and the rest of the middleware only ever
+// talks to this interface.
+type Sink interface {
+	// Write hands a batch of points to the sink. Implementations may buffer
+	// internally; callers that need the data durably stored should follow up
+	// with Flush.
+	Write(ctx context.Context, points []*Point) error
+
+	// Flush forces any buffered points to be sent to the backend.
+	Flush() error
+
+	// Close releases any resources held by the sink (HTTP clients, open
+	// files, ...). The sink must not be used after Close returns.
+	Close() error
+}
@@ -0,0 +1,75 @@
+package sink
+
+import (
+	"context"
+	"time"
+
+	client "github.com/influxdata/influxdb/client/v2"
+)
+
+// V1Config carries the settings needed to talk to an InfluxDB 1.x server
+// using InfluxQL databases and basic auth, matching the existing Register
+// behavior.
+type V1Config struct {
+	Address  string
+	Username string
+	Password string
+	Database string
+	Timeout  time.Duration
+}
+
+// v1Sink writes points to an InfluxDB 1.x server via the legacy HTTP client.
+// This preserves the behavior Register has always had.
+type v1Sink struct {
+	client client.Client
+	db     string
+}
+
+// NewV1Sink builds a Sink backed by an InfluxDB 1.x HTTP client.
+func NewV1Sink(cfg V1Config) (Sink, error) {
+	c, err := client.NewHTTPClient(client.HTTPConfig{
+		Addr:     cfg.Address,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		Timeout:  cfg.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := c.Ping(cfg.Timeout); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	return &v1Sink{client: c, db: cfg.Database}, nil
+}
+
+func (s *v1Sink) Write(ctx context.Context, points []*Point) error {
+	bp, err := client.NewBatchPoints(client.BatchPointsConfig{
+		Precision: "s",
+		Database:  s.db,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range points {
+		cp, err := client.NewPoint(p.Measurement, p.Tags, p.Fields, p.Time)
+		if err != nil {
+			return err
+		}
+		bp.AddPoint(cp)
+	}
+
+	return s.client.Write(bp)
+}
+
+func (s *v1Sink) Flush() error {
+	// The v1 HTTP client writes synchronously, so there is nothing to flush.
+	return nil
+}
+
+func (s *v1Sink) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,33 @@
+package influxdb
+
+import "regexp"
+
+// tagRule rewrites a tag value matching pattern to replacement before a point
+// is admitted into the cardinality guard, e.g. collapsing "/users/42" into
+// "/users/:id" so one route doesn't mint a new series per ID. An empty key
+// applies the rule to every tag on the point.
+type tagRule struct {
+	key         string
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// normalizeTags rewrites tags in place against rules, returning the same map
+// for convenience at call sites.
+func normalizeTags(tags map[string]string, rules []tagRule) map[string]string {
+	if len(rules) == 0 || len(tags) == 0 {
+		return tags
+	}
+
+	for k, v := range tags {
+		for _, rule := range rules {
+			if rule.key != "" && rule.key != k {
+				continue
+			}
+			v = rule.pattern.ReplaceAllString(v, rule.replacement)
+		}
+		tags[k] = v
+	}
+
+	return tags
+}
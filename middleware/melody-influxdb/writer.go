@@ -0,0 +1,191 @@
+package influxdb
+
+import (
+	"context"
+	"time"
+
+	"melody/logging"
+	"melody/middleware/melody-influxdb/sink"
+)
+
+// asyncWriter decouples metric collection from the InfluxDB write path. A
+// single goroutine reads off in, coalesces points into batches of up to
+// batchSize, and flushes either when a batch fills up or flushInterval
+// elapses, whichever comes first. Failed batches are retried with
+// exponential backoff before falling back to the ring buffer, so a slow or
+// unavailable server no longer stalls metric collection.
+type asyncWriter struct {
+	sink   sink.Sink
+	buf    *Buffer
+	logger logging.Logger
+
+	in            chan *sink.Point
+	batchSize     int
+	flushInterval time.Duration
+
+	retryInterval        time.Duration
+	retryExponentialBase float64
+	maxRetries           int
+	maxRetryTime         time.Duration
+}
+
+func newAsyncWriter(cfg influxdbConfig, s sink.Sink, buf *Buffer, logger logging.Logger) *asyncWriter {
+	return &asyncWriter{
+		sink:                 s,
+		buf:                  buf,
+		logger:               logger,
+		in:                   make(chan *sink.Point, cfg.batchSize*2),
+		batchSize:            cfg.batchSize,
+		flushInterval:        cfg.flushInterval,
+		retryInterval:        cfg.retryInterval,
+		retryExponentialBase: cfg.retryExponentialBase,
+		maxRetries:           cfg.maxRetries,
+		maxRetryTime:         cfg.maxRetryTime,
+	}
+}
+
+// Enqueue hands points to the writer goroutine. It never blocks the caller
+// on InfluxDB: once the channel buffer is full, points fall straight into
+// the ring buffer instead of backing up the collector.
+func (w *asyncWriter) Enqueue(points ...*sink.Point) {
+	for _, p := range points {
+		select {
+		case w.in <- p:
+		default:
+			w.buf.Add(p)
+		}
+	}
+}
+
+// Run owns the batching loop until ctx is canceled. It also retries whatever
+// is sitting in the ring buffer on every flush, so points that failed a
+// previous write eventually go out alongside fresh ones.
+func (w *asyncWriter) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*sink.Point, 0, w.batchSize)
+
+	flush := func(ctx context.Context) {
+		if pending := w.buf.Elements(); len(pending) > 0 {
+			batch = append(batch, pending...)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		// Chunk to batchSize even on retry: after an outage the ring buffer
+		// can hold up to bufferSize points, and sending those in one request
+		// is exactly the oversized-write spike batching is meant to avoid.
+		for _, chunk := range chunkPoints(batch, w.batchSize) {
+			if err := w.writeWithRetry(ctx, chunk); err != nil {
+				w.logger.Error("writing to the influx sink error after retries:", err.Error())
+				w.buf.Add(chunk...)
+			} else {
+				w.logger.Info(len(chunk), "datapoints sent to Influx")
+			}
+		}
+		batch = make([]*sink.Point, 0, w.batchSize)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.shutdown(batch)
+			return
+		case <-ticker.C:
+			flush(ctx)
+		case p := <-w.in:
+			batch = append(batch, p)
+			if len(batch) >= w.batchSize {
+				flush(ctx)
+			}
+		}
+	}
+}
+
+// shutdown flushes whatever this run of the batching loop is still holding
+// - pending, the ring buffer, and the sink's own internal buffering - before
+// releasing the sink's resources (HTTP clients, open files, ...). It runs
+// from the same goroutine as the batching loop above, after that loop has
+// already stopped reading off w.in, so it never races a concurrent
+// sink.Write. It flushes against a fresh context rather than the caller's
+// already-canceled one, so the final write still gets a chance to go out.
+func (w *asyncWriter) shutdown(pending []*sink.Point) {
+	if all := w.buf.Elements(); len(all) > 0 {
+		pending = append(pending, all...)
+	}
+
+	if len(pending) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), w.maxRetryTime)
+		for _, chunk := range chunkPoints(pending, w.batchSize) {
+			if err := w.writeWithRetry(ctx, chunk); err != nil {
+				w.logger.Error("writing to the influx sink on shutdown error:", err.Error())
+			}
+		}
+		cancel()
+	}
+
+	if err := w.sink.Flush(); err != nil {
+		w.logger.Error("flushing the influx sink on shutdown error:", err.Error())
+	}
+	if err := w.sink.Close(); err != nil {
+		w.logger.Error("closing the influx sink on shutdown error:", err.Error())
+	}
+}
+
+// writeWithRetry attempts to write the batch, backing off exponentially
+// between attempts, up to maxRetries or maxRetryTime, whichever is hit
+// first.
+func (w *asyncWriter) writeWithRetry(ctx context.Context, batch []*sink.Point) error {
+	deadline := time.Now().Add(w.maxRetryTime)
+	wait := w.retryInterval
+
+	var err error
+	for attempt := 0; attempt <= w.maxRetries; attempt++ {
+		if err = w.sink.Write(ctx, batch); err == nil {
+			return nil
+		}
+
+		if attempt == w.maxRetries || time.Now().Add(wait).After(deadline) {
+			break
+		}
+
+		w.logger.Debug("retrying influx write, attempt", attempt+1, "after", err.Error())
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+
+		wait = time.Duration(float64(wait) * w.retryExponentialBase)
+	}
+
+	return err
+}
+
+// chunkPoints splits points into slices of at most size, preserving order.
+// size <= 0 is treated as "no chunking".
+func chunkPoints(points []*sink.Point, size int) [][]*sink.Point {
+	if size <= 0 || len(points) <= size {
+		return [][]*sink.Point{points}
+	}
+
+	chunks := make([][]*sink.Point, 0, (len(points)+size-1)/size)
+	for len(points) > 0 {
+		n := size
+		if n > len(points) {
+			n = len(points)
+		}
+		chunks = append(chunks, points[:n])
+		points = points[n:]
+	}
+	return chunks
+}
+
+// Dropped reports how many points have been evicted because the ring buffer
+// was at capacity. It is surfaced via /ping so an operator can see metrics
+// loss without scraping logs.
+func (w *asyncWriter) Dropped() uint64 {
+	return w.buf.Dropped()
+}
@@ -0,0 +1,138 @@
+package influxdb
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+// fakeLogger discards everything; the writer tests care about retry/chunk
+// behavior, not log output.
+type fakeLogger struct{}
+
+func (fakeLogger) Debug(_ ...interface{})    {}
+func (fakeLogger) Info(_ ...interface{})     {}
+func (fakeLogger) Warning(_ ...interface{})  {}
+func (fakeLogger) Error(_ ...interface{})    {}
+func (fakeLogger) Critical(_ ...interface{}) {}
+func (fakeLogger) Fatal(_ ...interface{})    {}
+
+// failNTimesSink fails the first n writes, then succeeds, recording every
+// batch it was asked to write.
+type failNTimesSink struct {
+	failures int
+	calls    int
+	batches  [][]*sink.Point
+}
+
+func (s *failNTimesSink) Write(_ context.Context, points []*sink.Point) error {
+	s.calls++
+	s.batches = append(s.batches, points)
+	if s.calls <= s.failures {
+		return errors.New("write failed")
+	}
+	return nil
+}
+
+func (s *failNTimesSink) Flush() error { return nil }
+func (s *failNTimesSink) Close() error { return nil }
+
+func points(n int) []*sink.Point {
+	pts := make([]*sink.Point, n)
+	for i := range pts {
+		pts[i] = &sink.Point{Measurement: "cpu", Time: time.Unix(int64(i), 0)}
+	}
+	return pts
+}
+
+func TestWriteWithRetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		failures   int
+		maxRetries int
+		wantErr    bool
+		wantCalls  int
+	}{
+		{name: "succeeds first try", failures: 0, maxRetries: 3, wantErr: false, wantCalls: 1},
+		{name: "succeeds after retrying", failures: 2, maxRetries: 3, wantErr: false, wantCalls: 3},
+		{name: "exhausts retries and gives up", failures: 5, maxRetries: 2, wantErr: true, wantCalls: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &failNTimesSink{failures: tt.failures}
+			w := &asyncWriter{
+				sink:                 s,
+				logger:               fakeLogger{},
+				retryInterval:        time.Millisecond,
+				retryExponentialBase: 1,
+				maxRetries:           tt.maxRetries,
+				maxRetryTime:         time.Second,
+			}
+
+			err := w.writeWithRetry(context.Background(), points(1))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeWithRetry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if s.calls != tt.wantCalls {
+				t.Fatalf("sink.Write called %d times, want %d", s.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestWriteWithRetryRespectsContextCancellation(t *testing.T) {
+	s := &failNTimesSink{failures: 10}
+	w := &asyncWriter{
+		sink:                 s,
+		logger:               fakeLogger{},
+		retryInterval:        time.Hour,
+		retryExponentialBase: 1,
+		maxRetries:           5,
+		// Leave room in the retry budget for one more wait, so the canceled
+		// context (not the budget) is what ends the retry loop.
+		maxRetryTime: 2 * time.Hour,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := w.writeWithRetry(ctx, points(1))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("writeWithRetry() error = %v, want context.Canceled", err)
+	}
+	if s.calls != 1 {
+		t.Fatalf("sink.Write called %d times, want 1", s.calls)
+	}
+}
+
+func TestChunkPoints(t *testing.T) {
+	tests := []struct {
+		name       string
+		n          int
+		size       int
+		wantChunks []int
+	}{
+		{name: "fits in one chunk", n: 3, size: 5, wantChunks: []int{3}},
+		{name: "splits evenly", n: 6, size: 2, wantChunks: []int{2, 2, 2}},
+		{name: "splits with remainder", n: 7, size: 3, wantChunks: []int{3, 3, 1}},
+		{name: "no chunking when size is zero", n: 5, size: 0, wantChunks: []int{5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := chunkPoints(points(tt.n), tt.size)
+			if len(chunks) != len(tt.wantChunks) {
+				t.Fatalf("got %d chunks, want %d", len(chunks), len(tt.wantChunks))
+			}
+			for i, c := range chunks {
+				if len(c) != tt.wantChunks[i] {
+					t.Fatalf("chunk %d has %d points, want %d", i, len(c), tt.wantChunks[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"sync"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+// Bus fans newly written points out to every matching subscriber without
+// each dashboard issuing its own InfluxDB query. A single Bus is shared by
+// every websocket connection on the data server.
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[*Subscriber]struct{}
+}
+
+// NewBus creates an empty Bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscriber]struct{})}
+}
+
+// Publish fans p out to every subscriber whose filter matches it. A
+// subscriber that can't keep up is dropped instead of blocking the caller,
+// which is always the updateAndSendData collection loop, before it hands
+// the same points to the exporters.
+//
+// A subscriber's out channel is never closed (see Subscriber.close), so
+// sending on it here can never race a close and panic: for a subscriber
+// that has already closed, the point either lands in a buffer nobody reads
+// or its done channel is selected and the send is skipped.
+func (b *Bus) Publish(p *sink.Point) {
+	b.mu.RLock()
+	subs := make([]*Subscriber, 0, len(b.subs))
+	for s := range b.subs {
+		subs = append(subs, s)
+	}
+	b.mu.RUnlock()
+
+	for _, s := range subs {
+		if !s.matches(p) {
+			continue
+		}
+		select {
+		case s.out <- p:
+		case <-s.done:
+		default:
+			b.drop(s)
+		}
+	}
+}
+
+// Subscribe registers sub to receive future points matching its filter.
+func (b *Bus) Subscribe(sub *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[sub] = struct{}{}
+}
+
+// Unsubscribe removes sub from the bus; it is a no-op if sub was already
+// removed, e.g. because it was dropped for falling behind.
+func (b *Bus) Unsubscribe(sub *Subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs, sub)
+}
+
+// drop removes a subscriber that failed to keep up and signals its writer
+// goroutine to exit.
+func (b *Bus) drop(sub *Subscriber) {
+	b.mu.Lock()
+	_, ok := b.subs[sub]
+	delete(b.subs, sub)
+	b.mu.Unlock()
+
+	if ok {
+		sub.logger.Debug("dropping slow websocket subscriber for", sub.measurement)
+		sub.close()
+	}
+}
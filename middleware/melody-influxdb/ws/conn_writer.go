@@ -0,0 +1,34 @@
+package ws
+
+import (
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// connWriter serializes every write to a connection. gorilla/websocket
+// permits only one concurrent writer per *websocket.Conn, but a single
+// connection here is written to by several goroutines at once: the
+// heartbeat ticker, the read loop handling query ops, and one per active
+// Subscriber streaming data frames. All of them write through the same
+// connWriter instead of the raw conn so those writes can't interleave.
+type connWriter struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func newConnWriter(conn *websocket.Conn) *connWriter {
+	return &connWriter{conn: conn}
+}
+
+func (w *connWriter) WriteJSON(v interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.WriteJSON(v)
+}
+
+func (w *connWriter) WriteMessage(messageType int, data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.conn.WriteMessage(messageType, data)
+}
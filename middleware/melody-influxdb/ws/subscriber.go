@@ -0,0 +1,92 @@
+package ws
+
+import (
+	"sync"
+	"time"
+
+	"melody/logging"
+	"melody/middleware/melody-influxdb/sink"
+)
+
+// subscriberBufferSize bounds how many unsent points a subscriber can fall
+// behind by before Bus.Publish drops it.
+const subscriberBufferSize = 64
+
+// Subscriber is one client's subscription to a measurement/tag filter. It
+// owns the channel Bus.Publish fans points into and the goroutine that
+// writes them out over the websocket connection.
+type Subscriber struct {
+	conn   *connWriter
+	logger logging.Logger
+
+	out  chan *sink.Point
+	done chan struct{}
+
+	measurement string
+	tags        map[string]string
+	// interval caps how often this subscriber is sent a point for a given
+	// measurement; zero means every point is forwarded. Bus.Publish calls
+	// matches sequentially from a single goroutine, so lastSent needs no
+	// locking of its own.
+	interval time.Duration
+	lastSent time.Time
+
+	closeOnce sync.Once
+}
+
+func newSubscriber(conn *connWriter, logger logging.Logger, measurement string, tags map[string]string, interval time.Duration) *Subscriber {
+	return &Subscriber{
+		conn:        conn,
+		logger:      logger,
+		out:         make(chan *sink.Point, subscriberBufferSize),
+		done:        make(chan struct{}),
+		measurement: measurement,
+		tags:        tags,
+		interval:    interval,
+	}
+}
+
+// matches reports whether p should be delivered to this subscriber: the
+// measurement must match exactly, every tag the subscriber asked for must
+// be present with the same value (extra tags on p are ignored), and enough
+// time must have passed since the last point sent for this subscription.
+func (s *Subscriber) matches(p *sink.Point) bool {
+	if s.measurement != "" && s.measurement != p.Measurement {
+		return false
+	}
+	for k, v := range s.tags {
+		if p.Tags[k] != v {
+			return false
+		}
+	}
+	if s.interval > 0 && p.Time.Sub(s.lastSent) < s.interval {
+		return false
+	}
+	s.lastSent = p.Time
+	return true
+}
+
+// run streams points to the client until close is called or a write fails.
+// It never ranges over out directly: out is never closed (only done is), so
+// Bus.Publish can keep sending on it from another goroutine without racing
+// a close.
+func (s *Subscriber) run() {
+	for {
+		select {
+		case p := <-s.out:
+			if err := s.conn.WriteJSON(wsResponse{Op: "data", Data: p}); err != nil {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// close is safe to call more than once and from more than one goroutine
+// (the bus dropping a slow subscriber can race with the client
+// unsubscribing on its own). It closes done rather than out, so a
+// concurrent Bus.Publish send on out can never panic.
+func (s *Subscriber) close() {
+	s.closeOnce.Do(func() { close(s.done) })
+}
@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"melody/middleware/melody-influxdb/sink"
+)
+
+func TestSubscriberMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		measurement string
+		tags        map[string]string
+		point       *sink.Point
+		want        bool
+	}{
+		{
+			name:        "measurement mismatch",
+			measurement: "cpu",
+			point:       &sink.Point{Measurement: "mem"},
+			want:        false,
+		},
+		{
+			name:        "empty measurement matches anything",
+			measurement: "",
+			point:       &sink.Point{Measurement: "mem"},
+			want:        true,
+		},
+		{
+			name:        "tag filter satisfied",
+			measurement: "cpu",
+			tags:        map[string]string{"host": "a"},
+			point:       &sink.Point{Measurement: "cpu", Tags: map[string]string{"host": "a", "region": "us"}},
+			want:        true,
+		},
+		{
+			name:        "tag filter not satisfied",
+			measurement: "cpu",
+			tags:        map[string]string{"host": "a"},
+			point:       &sink.Point{Measurement: "cpu", Tags: map[string]string{"host": "b"}},
+			want:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSubscriber(nil, nil, tt.measurement, tt.tags, 0)
+			if got := s.matches(tt.point); got != tt.want {
+				t.Fatalf("matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscriberMatchesRespectsInterval(t *testing.T) {
+	s := newSubscriber(nil, nil, "cpu", nil, time.Minute)
+	base := time.Unix(0, 0)
+
+	if !s.matches(&sink.Point{Measurement: "cpu", Time: base}) {
+		t.Fatalf("first point should always match")
+	}
+	if s.matches(&sink.Point{Measurement: "cpu", Time: base.Add(10 * time.Second)}) {
+		t.Fatalf("point within the interval of the last sent point should not match")
+	}
+	if !s.matches(&sink.Point{Measurement: "cpu", Time: base.Add(time.Minute)}) {
+		t.Fatalf("point at the next interval boundary should match")
+	}
+}
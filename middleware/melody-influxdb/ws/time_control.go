@@ -0,0 +1,40 @@
+package ws
+
+import "sync"
+
+// TimeControl holds the speed and pause state that set how often the
+// websocket heartbeat pings, independent of the collector's own ttl ticker.
+// Subscription streams do not consult it.
+type TimeControl struct {
+	mu     sync.RWMutex
+	Speed  float64
+	Paused bool
+}
+
+var control = &TimeControl{Speed: 1}
+
+// RegisterWSTimeControl initializes the package-level TimeControl used by
+// every websocket connection.
+func RegisterWSTimeControl() {
+	control = &TimeControl{Speed: 1}
+}
+
+// Control returns the shared TimeControl instance.
+func Control() *TimeControl {
+	return control
+}
+
+// Set updates the replay speed and pause state.
+func (t *TimeControl) Set(speed float64, paused bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.Speed = speed
+	t.Paused = paused
+}
+
+// Get reads the current replay speed and pause state.
+func (t *TimeControl) Get() (float64, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.Speed, t.Paused
+}
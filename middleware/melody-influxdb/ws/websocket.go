@@ -0,0 +1,228 @@
+// Package ws serves the melody data server's websocket endpoint.
+package ws
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/websocket"
+	client "github.com/influxdata/influxdb/client/v2"
+	"melody/config"
+	"melody/logging"
+	"melody/middleware/melody-influxdb/middleware"
+	"melody/middleware/melody-influxdb/scope"
+)
+
+// errMissingTenant is returned by authenticate when TenantTag is set but
+// the connecting client's token carries no tenant claim, so the handshake
+// is rejected instead of the connection silently running unscoped queries.
+var errMissingTenant = errors.New("ws: token is missing the tenant claim required to scope this query")
+
+// heartbeatBase is the ping interval at TimeControl speed 1. The shared
+// TimeControl lets operators slow down or pause heartbeats; subscription
+// streams are not affected by it.
+const heartbeatBase = 30 * time.Second
+
+// WebSocketClient serves the data server's websocket endpoint. It shares
+// the v1 InfluxQL client with the REST /query handler. Unlike /query, every
+// websocket query is run as InfluxQL whatever its language field says, and
+// without /query's timeout and row limit.
+type WebSocketClient struct {
+	Client   client.Client
+	Upgrader websocket.Upgrader
+	Logger   logging.Logger
+	DB       string
+	Cfg      *config.ServiceConfig
+
+	// JWTSecret, when set, requires every connection to carry a valid
+	// bearer token as a "token" query parameter during the handshake.
+	JWTSecret string
+	// TenantTag is auto-injected into every query a client sends, scoping
+	// it the same way the REST /query endpoint does.
+	TenantTag string
+
+	// Bus fans out points written by updateAndSendData to every matching
+	// subscription, so N dashboards don't turn into N InfluxDB queries.
+	Bus *Bus
+}
+
+type wsRequest struct {
+	Op          string            `json:"op"`
+	Language    string            `json:"language"`
+	Query       string            `json:"query"`
+	Measurement string            `json:"measurement"`
+	Tags        map[string]string `json:"tags"`
+	Interval    string            `json:"interval"`
+}
+
+type wsResponse struct {
+	Op    string      `json:"op"`
+	Data  interface{} `json:"data,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// RegisterHandleFunc mounts the websocket endpoint on the default mux, the
+// same way the rest of the data server's handlers are wired up.
+func (wsc *WebSocketClient) RegisterHandleFunc() {
+	http.HandleFunc("/ws", wsc.handle)
+}
+
+func (wsc *WebSocketClient) handle(w http.ResponseWriter, r *http.Request) {
+	tenant, err := wsc.authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := wsc.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		wsc.Logger.Error("ws upgrade error:", err.Error())
+		return
+	}
+	defer conn.Close()
+
+	// Every writer of this connection (heartbeat, query responses, each
+	// subscriber's stream) writes through this one connWriter so their
+	// writes can't interleave on the wire.
+	writer := newConnWriter(conn)
+
+	heartbeatDone := make(chan struct{})
+	defer close(heartbeatDone)
+	go wsc.heartbeat(writer, heartbeatDone)
+
+	subs := make(map[*Subscriber]struct{})
+	defer func() {
+		for s := range subs {
+			wsc.Bus.Unsubscribe(s)
+			s.close()
+		}
+	}()
+
+	for {
+		var req wsRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+
+		switch req.Op {
+		case "subscribe":
+			wsc.handleSubscribe(writer, subs, req)
+		case "unsubscribe":
+			wsc.handleUnsubscribe(subs, req)
+		default:
+			wsc.handleQuery(writer, tenant, req)
+		}
+	}
+}
+
+// handleSubscribe registers a new subscription for the connection and
+// starts streaming matching points to it as they are written.
+func (wsc *WebSocketClient) handleSubscribe(writer *connWriter, subs map[*Subscriber]struct{}, req wsRequest) {
+	var interval time.Duration
+	if req.Interval != "" {
+		if d, err := time.ParseDuration(req.Interval); err == nil {
+			interval = d
+		}
+	}
+
+	sub := newSubscriber(writer, wsc.Logger, req.Measurement, req.Tags, interval)
+	subs[sub] = struct{}{}
+	wsc.Bus.Subscribe(sub)
+
+	go sub.run()
+}
+
+// handleUnsubscribe drops every active subscription on this connection for
+// the request's measurement; the request's tags are not compared.
+func (wsc *WebSocketClient) handleUnsubscribe(subs map[*Subscriber]struct{}, req wsRequest) {
+	for sub := range subs {
+		if sub.measurement != req.Measurement {
+			continue
+		}
+		wsc.Bus.Unsubscribe(sub)
+		sub.close()
+		delete(subs, sub)
+	}
+}
+
+// heartbeat pings the client at an interval governed by the shared
+// TimeControl, so a slowed-down or paused server doesn't flood idle
+// connections with pings either.
+func (wsc *WebSocketClient) heartbeat(conn *connWriter, done <-chan struct{}) {
+	ticker := time.NewTicker(heartbeatBase)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			speed, paused := Control().Get()
+			if paused {
+				continue
+			}
+			if speed <= 0 {
+				speed = 1
+			}
+			ticker.Reset(time.Duration(float64(heartbeatBase) / speed))
+
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (wsc *WebSocketClient) handleQuery(conn *connWriter, tenant string, req wsRequest) {
+	if req.Language == "" {
+		req.Language = "influxql"
+	}
+
+	if wsc.Client == nil {
+		conn.WriteJSON(wsResponse{Op: "query", Error: "no InfluxQL (v1) client configured"})
+		return
+	}
+
+	scoped := req.Query
+	if wsc.TenantTag != "" {
+		var err error
+		scoped, err = scope.InfluxQL(req.Query, wsc.TenantTag, tenant)
+		if err != nil {
+			conn.WriteJSON(wsResponse{Op: "query", Error: err.Error()})
+			return
+		}
+	}
+
+	resp, err := wsc.Client.Query(client.Query{Command: scoped, Database: wsc.DB})
+	if err != nil {
+		conn.WriteJSON(wsResponse{Op: "query", Error: err.Error()})
+		return
+	}
+
+	conn.WriteJSON(wsResponse{Op: "query", Data: resp.Results})
+}
+
+func (wsc *WebSocketClient) authenticate(r *http.Request) (tenant string, err error) {
+	if wsc.JWTSecret == "" {
+		return "", nil
+	}
+
+	token, err := middleware.ValidateBearerToken(wsc.JWTSecret, r.URL.Query().Get("token"))
+	if err != nil {
+		return "", err
+	}
+
+	if wsc.TenantTag != "" {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			tenant, _ = claims[wsc.TenantTag].(string)
+		}
+	}
+
+	if wsc.TenantTag != "" && tenant == "" {
+		return "", errMissingTenant
+	}
+
+	return tenant, nil
+}